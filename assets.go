@@ -0,0 +1,163 @@
+package webserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Assets serves the files of an fs.FS under content-addressed names, so that
+// they can be cached by browsers indefinitely. A file "js/app.js" is available
+// both as "js/app.<hash>.js" (cached as immutable) and as "js/app.js"
+// (revalidated on every request). The manifest mapping logical names to
+// fingerprinted URLs can optionally be served under the same prefix.
+type Assets struct {
+	prefix       string
+	manifestName string
+	fsys         fs.FS
+	hashes       map[string]string // logical name -> content hash
+	names        map[string]string // fingerprinted name -> logical name
+	manifest     map[string]string // logical name -> fingerprinted URL
+}
+
+// NewAssets fingerprints every file in fsys. URLs are generated relative to prefix.
+// If manifestName is not empty, the manifest is served under that name; it is an
+// error for fsys to contain a file with the same name.
+func NewAssets(prefix string, fsys fs.FS, manifestName string) (*Assets, error) {
+	a := &Assets{
+		prefix:       strings.TrimSuffix(prefix, "/") + "/",
+		manifestName: manifestName,
+		fsys:         fsys,
+		hashes:       make(map[string]string),
+		names:        make(map[string]string),
+		manifest:     make(map[string]string),
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		f, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		hash := hex.EncodeToString(h.Sum(nil))[:16]
+
+		ext := path.Ext(name)
+		fingerprinted := strings.TrimSuffix(name, ext) + "." + hash + ext
+
+		if manifestName != "" && (name == manifestName || fingerprinted == manifestName) {
+			return fmt.Errorf("webserver: asset %q conflicts with the manifest name %q", name, manifestName)
+		}
+
+		a.hashes[name] = hash
+		a.names[fingerprinted] = name
+		a.manifest[name] = a.prefix + fingerprinted
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Path returns the fingerprinted URL of the named asset, or the unversioned URL
+// if the asset is unknown.
+func (a *Assets) Path(name string) string {
+	if p, ok := a.manifest[name]; ok {
+		return p
+	}
+	return a.prefix + name
+}
+
+// Manifest returns a copy of the mapping from logical names to fingerprinted URLs.
+func (a *Assets) Manifest() map[string]string {
+	m := make(map[string]string, len(a.manifest))
+	for k, v := range a.manifest {
+		m[k] = v
+	}
+	return m
+}
+
+// ServeHTTP implements http.Handler.
+func (a *Assets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	name, ok := strings.CutPrefix(r.URL.Path, a.prefix)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if a.manifestName != "" && name == a.manifestName {
+		w.Header().Set("Cache-Control", "no-cache")
+		writeJSON(w, http.StatusOK, a.manifest)
+		return
+	}
+
+	if logical, ok := a.names[name]; ok {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		a.serveFile(w, r, logical)
+		return
+	}
+
+	if _, ok := a.hashes[name]; ok {
+		w.Header().Set("Cache-Control", "no-cache")
+		a.serveFile(w, r, name)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+func (a *Assets) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	f, err := a.fsys.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(b)
+	}
+
+	w.Header().Set("ETag", `"`+a.hashes[name]+`"`)
+	http.ServeContent(w, r, name, time.Time{}, content)
+}
+
+// ServeAssets fingerprints the files in fsys and serves them under prefix, along
+// with the manifest under manifestName if it is not empty (see NewAssets).
+// The returned Assets can be used to look up asset URLs, e.g. from templates.
+func (s *WebServer) ServeAssets(prefix string, fsys fs.FS, manifestName string) (*Assets, error) {
+	a, err := NewAssets(prefix, fsys, manifestName)
+	if err != nil {
+		return nil, err
+	}
+	s.router.PathPrefix(a.prefix).Handler(a)
+	return a, nil
+}
@@ -0,0 +1,49 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAssets(t *testing.T) {
+	fsys := fstest.MapFS{
+		"js/app.js":     {Data: []byte("alert(1)")},
+		"manifest.json": {Data: []byte(`{"name":"web app"}`)},
+	}
+
+	if _, err := NewAssets("/static", fsys, "manifest.json"); err == nil {
+		t.Error("NewAssets accepted a file that conflicts with the manifest name")
+	}
+
+	a, err := NewAssets("/static", fsys, "assets-manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		a.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := get(a.Path("js/app.js")); rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Errorf("fingerprinted asset: status %d, Cache-Control %q", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+	if rec := get("/static/js/app.js"); rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("plain asset: status %d, Cache-Control %q", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+	if rec := get("/static/manifest.json"); rec.Body.String() != `{"name":"web app"}` {
+		t.Errorf("web app manifest = %q, want the file from the FS", rec.Body.String())
+	}
+
+	var manifest map[string]string
+	if err := json.Unmarshal(get("/static/assets-manifest.json").Body.Bytes(), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest["js/app.js"] != a.Path("js/app.js") {
+		t.Errorf("manifest entry = %q, want %q", manifest["js/app.js"], a.Path("js/app.js"))
+	}
+}
@@ -0,0 +1,352 @@
+package webserver
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResponseCacheConfig configures a ResponseCache. Zero values use the defaults noted below.
+type ResponseCacheConfig struct {
+	TTL          time.Duration                // How long responses are cached, 1 minute by default.
+	Scope        func(*http.Request) string   // Partitions the cache, e.g. by user or tenant. See ResponseCache.
+	MaxEntries   int                          // Maximum number of cached responses, 1000 by default.
+	MaxBytes     int                          // Maximum total size of cached bodies, 64 MiB by default.
+	MaxBodyBytes int                          // Responses with larger bodies are not cached, 1 MiB by default.
+	HonorNoCache bool                         // Let requests sent with Cache-Control: no-cache bypass the cache.
+	Invalidates  func(*http.Request) []string // Path prefixes a successful write invalidates, the write's own path by default.
+}
+
+// ResponseCache caches successful GET responses in memory for a fixed TTL.
+// It is opt-in: apply Middleware to the routes or subrouters serving expensive,
+// idempotent reads. It only caches on the server and leaves the Cache-Control
+// sent to clients to the handlers.
+//
+// Responses are keyed by the request URI and, if set, a scope derived from the
+// request (typically the authenticated user or tenant), so that responses are
+// never shared across scopes. A cached response is served without running the
+// handler, so without a Scope, requests carrying an Authorization or Cookie
+// header bypass the cache rather than seeing a response authorized for someone
+// else. Responses setting cookies or varying on every request (Vary: *) are
+// never cached; other Vary headers are matched on lookup. When the cache is
+// full, the oldest responses are evicted first.
+//
+// Successful POST, PUT, PATCH and DELETE requests passing through the middleware
+// invalidate the path prefixes returned by Invalidates. By default this is only
+// the write's own path, so a PUT /teams/1 does not invalidate GET /teams; set
+// Invalidates, or call Invalidate directly, when writes affect other resources.
+// Reads still in flight when their path is invalidated are not cached.
+type ResponseCache struct {
+	cfg ResponseCacheConfig
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // Values are *cachedResponse.
+	order   *list.List               // Oldest first; with a fixed TTL this is also expiry order.
+	bytes   int
+
+	gen         uint64            // Incremented by every invalidation.
+	invalidated map[string]uint64 // Path prefix -> generation of its last invalidation, while older reads are in flight.
+	reads       map[uint64]int    // Generation a read started in -> number of such reads in flight.
+}
+
+type cachedResponse struct {
+	key     string
+	path    string
+	vary    map[string]string // Request header -> value the response was generated for.
+	status  int
+	header  http.Header
+	body    []byte
+	created time.Time
+	expires time.Time
+}
+
+// NewResponseCache creates a cache.
+func NewResponseCache(cfg ResponseCacheConfig) *ResponseCache {
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Minute
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = 1000
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 64 << 20
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = 1 << 20
+	}
+	cfg.MaxBodyBytes = min(cfg.MaxBodyBytes, cfg.MaxBytes)
+
+	return &ResponseCache{
+		cfg:         cfg,
+		now:         time.Now,
+		entries:     make(map[string]*list.Element),
+		order:       list.New(),
+		invalidated: make(map[string]uint64),
+		reads:       make(map[uint64]int),
+	}
+}
+
+// Invalidate removes all cached responses whose path starts with pathPrefix.
+func (c *ResponseCache) Invalidate(pathPrefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	if len(c.reads) > 0 {
+		c.invalidated[pathPrefix] = c.gen
+	}
+	for _, el := range c.entries {
+		if strings.HasPrefix(el.Value.(*cachedResponse).path, pathPrefix) {
+			c.remove(el)
+		}
+	}
+}
+
+// Purge removes all cached responses.
+func (c *ResponseCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	if len(c.reads) > 0 {
+		c.invalidated[""] = c.gen
+	}
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+	c.bytes = 0
+}
+
+// Middleware serves cached responses and caches new ones.
+func (c *ResponseCache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			rec := &cacheRecorder{ResponseWriter: w, status: http.StatusOK, discard: true}
+			next.ServeHTTP(rec, r)
+			if rec.status < http.StatusBadRequest {
+				c.invalidateFor(r)
+			}
+			return
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.URL.RequestURI()
+		if c.cfg.Scope != nil {
+			key = c.cfg.Scope(r) + " " + key
+		} else if r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !c.cfg.HonorNoCache || !strings.Contains(r.Header.Get("Cache-Control"), "no-cache") {
+			if e := c.get(key, r); e != nil {
+				c.serve(w, e)
+				return
+			}
+		}
+
+		start := c.beginRead()
+		defer c.endRead(start)
+
+		rec := &cacheRecorder{ResponseWriter: w, status: http.StatusOK, limit: c.cfg.MaxBodyBytes}
+		rec.Header().Set("X-Cache", "MISS")
+		next.ServeHTTP(rec, r)
+
+		if rec.status != http.StatusOK || rec.overflow || !c.cacheable(rec.Header()) {
+			return
+		}
+
+		vary := make(map[string]string)
+		for _, v := range rec.Header().Values("Vary") {
+			for _, name := range strings.Split(v, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					name = http.CanonicalHeaderKey(name)
+					vary[name] = r.Header.Get(name)
+				}
+			}
+		}
+
+		now := c.now()
+		c.set(start, &cachedResponse{
+			key:     key,
+			path:    r.URL.Path,
+			vary:    vary,
+			status:  rec.status,
+			header:  rec.Header().Clone(),
+			body:    rec.body.Bytes(),
+			created: now,
+			expires: now.Add(c.cfg.TTL),
+		})
+	})
+}
+
+func (c *ResponseCache) invalidateFor(r *http.Request) {
+	if c.cfg.Invalidates == nil {
+		c.Invalidate(r.URL.Path)
+		return
+	}
+	for _, prefix := range c.cfg.Invalidates(r) {
+		c.Invalidate(prefix)
+	}
+}
+
+func (c *ResponseCache) cacheable(h http.Header) bool {
+	cacheControl := h.Get("Cache-Control")
+	switch {
+	case strings.Contains(cacheControl, "no-store"):
+		return false
+	case strings.Contains(cacheControl, "private") && c.cfg.Scope == nil:
+		return false
+	case h.Get("Set-Cookie") != "":
+		// Replaying a cookie would hand one client's session to another.
+		return false
+	case strings.Contains(strings.Join(h.Values("Vary"), ","), "*"):
+		return false
+	}
+	return true
+}
+
+func (c *ResponseCache) get(key string, r *http.Request) *cachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*cachedResponse)
+	if !c.now().Before(e.expires) {
+		c.remove(el)
+		return nil
+	}
+	for name, value := range e.vary {
+		if r.Header.Get(name) != value {
+			return nil
+		}
+	}
+	return e
+}
+
+// set stores e unless its path was invalidated after generation start, when
+// the read producing it began.
+func (c *ResponseCache) set(start uint64, e *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for prefix, gen := range c.invalidated {
+		if gen > start && strings.HasPrefix(e.path, prefix) {
+			return
+		}
+	}
+
+	if el, ok := c.entries[e.key]; ok {
+		c.remove(el)
+	}
+
+	// Drop expired entries, then the oldest ones until the new entry fits.
+	for el := c.order.Front(); el != nil; el = c.order.Front() {
+		old := el.Value.(*cachedResponse)
+		fits := len(c.entries) < c.cfg.MaxEntries && c.bytes+len(e.body) <= c.cfg.MaxBytes
+		if fits && e.created.Before(old.expires) {
+			break
+		}
+		c.remove(el)
+	}
+
+	c.entries[e.key] = c.order.PushBack(e)
+	c.bytes += len(e.body)
+}
+
+// beginRead registers a read in flight and returns the current generation.
+func (c *ResponseCache) beginRead() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reads[c.gen]++
+	return c.gen
+}
+
+// endRead unregisters a read started in generation start and forgets the
+// invalidations that no read in flight predates.
+func (c *ResponseCache) endRead(start uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.reads[start]--; c.reads[start] == 0 {
+		delete(c.reads, start)
+	}
+	oldest := c.gen
+	for gen := range c.reads {
+		oldest = min(oldest, gen)
+	}
+	for prefix, gen := range c.invalidated {
+		if gen <= oldest {
+			delete(c.invalidated, prefix)
+		}
+	}
+}
+
+// remove deletes an entry; c.mu must be held.
+func (c *ResponseCache) remove(el *list.Element) {
+	e := c.order.Remove(el).(*cachedResponse)
+	delete(c.entries, e.key)
+	c.bytes -= len(e.body)
+}
+
+func (c *ResponseCache) serve(w http.ResponseWriter, e *cachedResponse) {
+	h := w.Header()
+	for k, v := range e.header {
+		h[k] = v
+	}
+	h.Set("X-Cache", "HIT")
+	h.Set("Age", strconv.Itoa(int(c.now().Sub(e.created).Seconds())))
+	w.WriteHeader(e.status)
+	w.Write(e.body)
+}
+
+// cacheRecorder passes the response through while keeping a copy of it.
+type cacheRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	discard     bool // Only record the status, not the body.
+	limit       int  // Maximum body size to keep.
+	overflow    bool // The body exceeded limit and was not kept.
+	body        bytes.Buffer
+}
+
+func (w *cacheRecorder) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheRecorder) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.discard && !w.overflow {
+		if w.body.Len()+len(b) > w.limit {
+			w.overflow = true
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *cacheRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
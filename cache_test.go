@@ -0,0 +1,215 @@
+package webserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type cacheFixture struct {
+	cache *ResponseCache
+	h     http.Handler
+	calls int
+	clock time.Time
+}
+
+// newCacheFixture serves "<path> #<call>" for GETs, letting the handler customise headers per path.
+func newCacheFixture(cfg ResponseCacheConfig, headers func(path string, h http.Header)) *cacheFixture {
+	f := &cacheFixture{clock: time.Now()}
+	f.cache = NewResponseCache(cfg)
+	f.cache.now = func() time.Time { return f.clock }
+	f.h = f.cache.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			return
+		}
+		f.calls++
+		if headers != nil {
+			headers(r.URL.Path, w.Header())
+		}
+		fmt.Fprintf(w, "%s #%d", r.URL.Path, f.calls)
+	}))
+	return f
+}
+
+func (f *cacheFixture) do(method, target string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	f.h.ServeHTTP(rec, r)
+	return rec
+}
+
+func (f *cacheFixture) expect(t *testing.T, rec *httptest.ResponseRecorder, xCache, body string) {
+	t.Helper()
+	if got := rec.Header().Get("X-Cache"); got != xCache {
+		t.Errorf("X-Cache = %q, want %q", got, xCache)
+	}
+	if got := rec.Body.String(); got != body {
+		t.Errorf("body = %q, want %q", got, body)
+	}
+}
+
+func TestResponseCacheHitMissExpiry(t *testing.T) {
+	f := newCacheFixture(ResponseCacheConfig{TTL: time.Minute}, nil)
+
+	f.expect(t, f.do(http.MethodGet, "/stats"), "MISS", "/stats #1")
+	rec := f.do(http.MethodGet, "/stats")
+	f.expect(t, rec, "HIT", "/stats #1")
+	if got := rec.Header().Get("Cache-Control"); got != "" {
+		t.Errorf("Cache-Control = %q, want none added for clients", got)
+	}
+
+	f.clock = f.clock.Add(2 * time.Minute)
+	f.expect(t, f.do(http.MethodGet, "/stats"), "MISS", "/stats #2")
+}
+
+func TestResponseCacheScope(t *testing.T) {
+	f := newCacheFixture(ResponseCacheConfig{TTL: time.Minute, Scope: func(r *http.Request) string { return r.Header.Get("X-User") }}, nil)
+
+	f.expect(t, f.do(http.MethodGet, "/stats", "X-User", "a"), "MISS", "/stats #1")
+	f.expect(t, f.do(http.MethodGet, "/stats", "X-User", "b"), "MISS", "/stats #2")
+	f.expect(t, f.do(http.MethodGet, "/stats", "X-User", "a"), "HIT", "/stats #1")
+}
+
+func TestResponseCacheInvalidation(t *testing.T) {
+	f := newCacheFixture(ResponseCacheConfig{TTL: time.Minute}, nil)
+
+	f.do(http.MethodGet, "/teams")
+	f.do(http.MethodGet, "/teams/1")
+
+	// By default a write only invalidates its own path.
+	f.do(http.MethodPut, "/teams/1")
+	f.expect(t, f.do(http.MethodGet, "/teams/1"), "MISS", "/teams/1 #3")
+	f.expect(t, f.do(http.MethodGet, "/teams"), "HIT", "/teams #1")
+
+	f.cache.Invalidate("/teams")
+	f.expect(t, f.do(http.MethodGet, "/teams"), "MISS", "/teams #4")
+
+	g := newCacheFixture(ResponseCacheConfig{TTL: time.Minute, Invalidates: func(r *http.Request) []string { return []string{"/teams"} }}, nil)
+	g.do(http.MethodGet, "/teams")
+	g.do(http.MethodPut, "/teams/1")
+	g.expect(t, g.do(http.MethodGet, "/teams"), "MISS", "/teams #2")
+}
+
+func TestResponseCacheNoCacheRequests(t *testing.T) {
+	f := newCacheFixture(ResponseCacheConfig{TTL: time.Minute}, nil)
+	f.do(http.MethodGet, "/stats")
+	f.expect(t, f.do(http.MethodGet, "/stats", "Cache-Control", "no-cache"), "HIT", "/stats #1")
+
+	g := newCacheFixture(ResponseCacheConfig{TTL: time.Minute, HonorNoCache: true}, nil)
+	g.do(http.MethodGet, "/stats")
+	g.expect(t, g.do(http.MethodGet, "/stats", "Cache-Control", "no-cache"), "MISS", "/stats #2")
+}
+
+func TestResponseCacheUncacheable(t *testing.T) {
+	f := newCacheFixture(ResponseCacheConfig{TTL: time.Minute}, func(path string, h http.Header) {
+		switch path {
+		case "/cookie":
+			h.Set("Set-Cookie", "session=user1")
+			h.Set("Cache-Control", "public, max-age=60")
+		case "/private":
+			h.Set("Cache-Control", "private")
+		case "/no-store":
+			h.Set("Cache-Control", "no-store")
+		case "/vary-all":
+			h.Set("Vary", "*")
+		}
+	})
+
+	for _, path := range []string{"/cookie", "/private", "/no-store", "/vary-all"} {
+		f.do(http.MethodGet, path)
+		if rec := f.do(http.MethodGet, path); rec.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("%s was served from the cache", path)
+		}
+	}
+}
+
+func TestResponseCacheVary(t *testing.T) {
+	f := newCacheFixture(ResponseCacheConfig{TTL: time.Minute}, func(path string, h http.Header) {
+		h.Set("Vary", "Accept-Language")
+	})
+
+	f.expect(t, f.do(http.MethodGet, "/stats", "Accept-Language", "en"), "MISS", "/stats #1")
+	f.expect(t, f.do(http.MethodGet, "/stats", "Accept-Language", "en"), "HIT", "/stats #1")
+	f.expect(t, f.do(http.MethodGet, "/stats", "Accept-Language", "de"), "MISS", "/stats #2")
+}
+
+func TestResponseCacheLimits(t *testing.T) {
+	f := newCacheFixture(ResponseCacheConfig{TTL: time.Minute, MaxEntries: 2}, nil)
+
+	f.do(http.MethodGet, "/stats?x=1")
+	f.do(http.MethodGet, "/stats?x=2")
+	f.do(http.MethodGet, "/stats?x=3")
+	if n := len(f.cache.entries); n != 2 {
+		t.Errorf("entries = %d, want 2", n)
+	}
+	f.expect(t, f.do(http.MethodGet, "/stats?x=1"), "MISS", "/stats #4")
+	f.expect(t, f.do(http.MethodGet, "/stats?x=3"), "HIT", "/stats #3")
+
+	g := newCacheFixture(ResponseCacheConfig{TTL: time.Minute, MaxBodyBytes: 8, MaxBytes: 12}, nil)
+	g.do(http.MethodGet, "/a-long-path")
+	if n := len(g.cache.entries); n != 0 {
+		t.Errorf("oversized body was cached (%d entries)", n)
+	}
+
+	// Each body is 5 bytes, so the third one only fits after evicting the oldest.
+	for _, p := range []string{"/a", "/b", "/c"} {
+		g.do(http.MethodGet, p)
+	}
+	if n := len(g.cache.entries); n != 2 || g.cache.bytes != 10 {
+		t.Errorf("cache holds %d entries, %d bytes; want 2 entries, 10 bytes", n, g.cache.bytes)
+	}
+	g.expect(t, g.do(http.MethodGet, "/c"), "HIT", "/c #4")
+	g.expect(t, g.do(http.MethodGet, "/a"), "MISS", "/a #5")
+}
+
+func TestResponseCacheCredentialsWithoutScope(t *testing.T) {
+	f := newCacheFixture(ResponseCacheConfig{}, nil)
+	f.h = f.cache.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer alice" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, "alice's data")
+	}))
+
+	f.do(http.MethodGet, "/me", "Authorization", "Bearer alice")
+	if rec := f.do(http.MethodGet, "/me", "Authorization", "Bearer mallory"); rec.Code != http.StatusForbidden {
+		t.Errorf("mallory got %d %q, want 403", rec.Code, rec.Body.String())
+	}
+	if n := len(f.cache.entries); n != 0 {
+		t.Errorf("credentialed response was cached without a scope (%d entries)", n)
+	}
+}
+
+func TestResponseCacheInvalidationDuringRead(t *testing.T) {
+	// The first GET blocks in the handler until a PUT to the same path has completed.
+	var first sync.Once
+	entered, release := make(chan struct{}), make(chan struct{})
+	f := newCacheFixture(ResponseCacheConfig{TTL: time.Minute}, func(path string, h http.Header) {
+		first.Do(func() {
+			close(entered)
+			<-release
+		})
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.do(http.MethodGet, "/slow")
+	}()
+	<-entered
+	f.do(http.MethodPut, "/slow")
+	close(release)
+	<-done
+
+	f.expect(t, f.do(http.MethodGet, "/slow"), "MISS", "/slow #2")
+	if n := len(f.cache.invalidated); n != 0 {
+		t.Errorf("%d invalidations kept with no reads in flight", n)
+	}
+}
@@ -2,9 +2,11 @@ package webserver
 
 // Config ...
 type Config struct {
-	Addr string    `json:"address"`
-	Port int       `json:"port"`
-	TLS  TLSConfig `json:"tls"`
+	Addr         string       `json:"address"`
+	Port         int          `json:"port"`
+	TLS          TLSConfig    `json:"tls"`
+	Limits       LimitsConfig `json:"limits"`
+	DrainDelayMS int          `json:"drainDelayMs"` // How long Stop reports not ready before shutting down.
 }
 
 // TLSConfig ...
@@ -13,3 +15,12 @@ type TLSConfig struct {
 	CertFile string `json:"certPath"`
 	KeyFile  string `json:"keyPath"`
 }
+
+// LimitsConfig controls load shedding. Limiting is disabled when MaxInFlight is 0.
+type LimitsConfig struct {
+	MaxInFlight    int      `json:"maxInFlight"`    // Maximum number of requests handled concurrently.
+	MaxQueue       int      `json:"maxQueue"`       // Maximum number of requests waiting for a slot.
+	QueueTimeoutMS int      `json:"queueTimeoutMs"` // How long a queued request waits before being shed; 0 waits until the client goes away.
+	RetryAfter     int      `json:"retryAfter"`     // Seconds sent in the Retry-After header of shed requests.
+	ExemptPaths    []string `json:"exemptPaths"`    // Paths that are never limited. The HandleHealth endpoints are always exempt.
+}
@@ -0,0 +1,72 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+)
+
+// Build information, injected at build time with ldflags, e.g.
+//
+//	go build -ldflags "-X github.com/artha-au/webserver.Version=1.2.3 \
+//		-X github.com/artha-au/webserver.Commit=$(git rev-parse HEAD) \
+//		-X github.com/artha-au/webserver.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// BuildInfo describes the running binary.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+// GetBuildInfo returns the build information of the running binary.
+func GetBuildInfo() BuildInfo {
+	return BuildInfo{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+}
+
+// healthPaths are the endpoints registered by HandleHealth.
+var healthPaths = []string{"/health", "/ready", "/version"}
+
+type statusResponse struct {
+	Status string `json:"status"`
+	BuildInfo
+}
+
+// HandleHealth registers the /health, /ready and /version endpoints on the router.
+// /health reports that the process is up, /ready reports whether the server is
+// listening and not stopping (503 during the Config.DrainDelayMS window of Stop),
+// and /version returns the BuildInfo.
+func (s *WebServer) HandleHealth() {
+	s.router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, statusResponse{Status: "ok", BuildInfo: GetBuildInfo()})
+	}).Methods(http.MethodGet, http.MethodHead)
+
+	s.router.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+		if !s.ready.Load() {
+			writeJSON(w, http.StatusServiceUnavailable, statusResponse{Status: "unavailable", BuildInfo: GetBuildInfo()})
+			return
+		}
+		writeJSON(w, http.StatusOK, statusResponse{Status: "ready", BuildInfo: GetBuildInfo()})
+	}).Methods(http.MethodGet, http.MethodHead)
+
+	s.router.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, GetBuildInfo())
+	}).Methods(http.MethodGet, http.MethodHead)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
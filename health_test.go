@@ -0,0 +1,68 @@
+package webserver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func waitForStatus(t *testing.T, url string, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == want {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("GET %s never returned %d", url, want)
+}
+
+func TestReadyDuringDrain(t *testing.T) {
+	port := freePort(t)
+	s := New(Config{Addr: "127.0.0.1", Port: port, DrainDelayMS: 500})
+	s.HandleHealth()
+	s.Start()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/ready", port)
+	waitForStatus(t, url, http.StatusOK)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- s.Stop() }()
+
+	waitForStatus(t, url, http.StatusServiceUnavailable)
+	if err := <-stopped; err != nil {
+		t.Errorf("Stop() = %v", err)
+	}
+}
+
+func TestNotReadyWhenListenFails(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	s := New(Config{Addr: "127.0.0.1", Port: ln.Addr().(*net.TCPAddr).Port})
+	if err := s.Run(); err == nil {
+		t.Fatal("Run() succeeded on a port that is in use")
+	}
+	if s.ready.Load() {
+		t.Error("server reports ready although it is not listening")
+	}
+}
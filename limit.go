@@ -0,0 +1,75 @@
+package webserver
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// LimitInFlight returns a middleware that allows at most cfg.MaxInFlight requests
+// to be handled at once. Up to cfg.MaxQueue further requests wait for a free slot
+// for at most cfg.QueueTimeoutMS, or until the client goes away if it is 0;
+// anything beyond that is rejected with 503 Service Unavailable and a Retry-After
+// header. Requests for cfg.ExemptPaths are never limited, and nothing is limited
+// if cfg.MaxInFlight is 0.
+func LimitInFlight(cfg LimitsConfig) mux.MiddlewareFunc {
+	if cfg.MaxInFlight <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	slots := make(chan struct{}, cfg.MaxInFlight)
+	timeout := time.Duration(cfg.QueueTimeoutMS) * time.Millisecond
+	retryAfter := strconv.Itoa(max(cfg.RetryAfter, 1))
+
+	var queued atomic.Int64
+
+	shed := func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", retryAfter)
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(cfg.ExemptPaths, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			select {
+			case slots <- struct{}{}:
+			default:
+				if queued.Add(1) > int64(cfg.MaxQueue) {
+					queued.Add(-1)
+					shed(w)
+					return
+				}
+
+				var expired <-chan time.Time
+				if timeout > 0 {
+					timer := time.NewTimer(timeout)
+					defer timer.Stop()
+					expired = timer.C
+				}
+
+				select {
+				case slots <- struct{}{}:
+					queued.Add(-1)
+				case <-expired:
+					queued.Add(-1)
+					shed(w)
+					return
+				case <-r.Context().Done():
+					queued.Add(-1)
+					return
+				}
+			}
+			defer func() { <-slots }()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
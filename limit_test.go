@@ -0,0 +1,158 @@
+package webserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// blockingHandler signals on entered when a request starts and holds it until release is closed.
+func blockingHandler(entered chan<- struct{}, release <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	})
+}
+
+func serveAsync(h http.Handler, r *http.Request) <-chan *httptest.ResponseRecorder {
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		done <- rec
+	}()
+	return done
+}
+
+func TestLimitInFlightDisabled(t *testing.T) {
+	h := LimitInFlight(LimitsConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200 with limiting disabled", rec.Code)
+	}
+}
+
+func TestLimitInFlightQueueFull(t *testing.T) {
+	entered, release := make(chan struct{}, 2), make(chan struct{})
+	h := LimitInFlight(LimitsConfig{MaxInFlight: 1, RetryAfter: 7})(blockingHandler(entered, release))
+
+	first := serveAsync(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	<-entered
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "7" {
+		t.Errorf("Retry-After = %q, want 7", got)
+	}
+
+	close(release)
+	if rec := <-first; rec.Code != http.StatusOK {
+		t.Errorf("first request status = %d, want 200", rec.Code)
+	}
+}
+
+func TestLimitInFlightQueueTimeout(t *testing.T) {
+	entered, release := make(chan struct{}, 2), make(chan struct{})
+	defer close(release)
+	h := LimitInFlight(LimitsConfig{MaxInFlight: 1, MaxQueue: 1, QueueTimeoutMS: 50})(blockingHandler(entered, release))
+
+	serveAsync(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	<-entered
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("request was shed after %v, before the queue timeout", elapsed)
+	}
+}
+
+func TestLimitInFlightQueueWithoutTimeout(t *testing.T) {
+	entered, release := make(chan struct{}, 2), make(chan struct{})
+	h := LimitInFlight(LimitsConfig{MaxInFlight: 1, MaxQueue: 10})(blockingHandler(entered, release))
+
+	first := serveAsync(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	<-entered
+	second := serveAsync(h, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	select {
+	case rec := <-second:
+		t.Fatalf("queued request finished early with status %d", rec.Code)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	for _, done := range []<-chan *httptest.ResponseRecorder{first, second} {
+		if rec := <-done; rec.Code != http.StatusOK {
+			t.Errorf("status = %d, want 200", rec.Code)
+		}
+	}
+}
+
+func TestLimitInFlightClientGone(t *testing.T) {
+	entered, release := make(chan struct{}, 2), make(chan struct{})
+	defer close(release)
+	h := LimitInFlight(LimitsConfig{MaxInFlight: 1, MaxQueue: 1})(blockingHandler(entered, release))
+
+	serveAsync(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	<-entered
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := serveAsync(h, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("queued request was not released when its client went away")
+	}
+}
+
+func TestLimitInFlightExemptPaths(t *testing.T) {
+	entered, release := make(chan struct{}, 2), make(chan struct{})
+	defer close(release)
+	limited := blockingHandler(entered, release)
+	h := LimitInFlight(LimitsConfig{MaxInFlight: 1, ExemptPaths: []string{"/ready"}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ready" {
+			limited.ServeHTTP(w, r)
+		}
+	}))
+
+	serveAsync(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	<-entered
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("exempt path status = %d, want 200", rec.Code)
+	}
+}
+
+func TestHealthBypassesServerLimit(t *testing.T) {
+	s := New(Config{Limits: LimitsConfig{MaxInFlight: 1}})
+	s.HandleHealth()
+	entered, release := make(chan struct{}, 1), make(chan struct{})
+	defer close(release)
+	s.Router().Handle("/slow", blockingHandler(entered, release))
+	s.prepare()
+
+	serveAsync(s.server.Handler, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	<-entered
+
+	if rec := serve(s, "/health"); rec.Code != http.StatusOK {
+		t.Errorf("/health status under load = %d, want 200", rec.Code)
+	}
+	if rec := serve(s, "/slow"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/slow status under load = %d, want 503", rec.Code)
+	}
+}
@@ -0,0 +1,187 @@
+package webserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// MiddlewarePhase determines where in the request pipeline a middleware runs.
+type MiddlewarePhase int
+
+const (
+	// PreRouting middleware wraps the router and runs for every request,
+	// before a route is matched (including requests that end in a 404).
+	PreRouting MiddlewarePhase = iota
+	// PostRouting middleware runs only for requests that matched a route,
+	// so mux.Vars and mux.CurrentRoute are available to it.
+	PostRouting
+)
+
+// String returns the name of the phase.
+func (p MiddlewarePhase) String() string {
+	switch p {
+	case PreRouting:
+		return "pre-routing"
+	case PostRouting:
+		return "post-routing"
+	default:
+		return "unknown"
+	}
+}
+
+// Names of the middleware installed by New, for use in Before and After.
+const (
+	RecoverMiddleware = "recover"
+	LimitMiddleware   = "limit"
+)
+
+// Middleware is a named middleware with ordering constraints.
+type Middleware struct {
+	Name   string          // Unique name, referenced by the Before and After of other middleware. May be empty.
+	Phase  MiddlewarePhase // Phase the middleware runs in.
+	Before []string        // Names of middleware this one must run before.
+	After  []string        // Names of middleware this one must run after.
+	Func   mux.MiddlewareFunc
+}
+
+var (
+	// ErrServerStarted is returned when middleware is added after the server has started.
+	ErrServerStarted = errors.New("webserver: middleware cannot be added after the server has started")
+	// ErrUnknownPhase is returned when middleware is added with an invalid phase.
+	ErrUnknownPhase = errors.New("webserver: unknown middleware phase")
+	// ErrDuplicateMiddleware is returned when a middleware name is registered twice.
+	ErrDuplicateMiddleware = errors.New("webserver: duplicate middleware")
+	// ErrMiddlewareOrder is returned when ordering constraints cannot be satisfied.
+	ErrMiddlewareOrder = errors.New("webserver: conflicting middleware order")
+)
+
+// Use adds anonymous middleware to the given phase. It is shorthand for Register
+// without a name or ordering constraints.
+func (s *WebServer) Use(phase MiddlewarePhase, mw ...mux.MiddlewareFunc) error {
+	for _, fn := range mw {
+		if err := s.Register(Middleware{Phase: phase, Func: fn}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Register adds a middleware. Within a phase, middleware runs in the order it
+// was registered unless its Before and After constraints require otherwise, and
+// all PreRouting middleware runs before any PostRouting middleware. Constraints
+// on names that are not registered (yet) are ignored.
+//
+// Register returns an error if the name is already taken, or if the constraints
+// contradict each other or the phases. It must be called before Start or Run.
+func (s *WebServer) Register(m Middleware) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return ErrServerStarted
+	}
+	if m.Phase != PreRouting && m.Phase != PostRouting {
+		return ErrUnknownPhase
+	}
+	if m.Func == nil {
+		return fmt.Errorf("webserver: middleware %q has no Func", m.Name)
+	}
+	if m.Name != "" && slices.ContainsFunc(s.middleware, func(o Middleware) bool { return o.Name == m.Name }) {
+		return fmt.Errorf("%w: %q", ErrDuplicateMiddleware, m.Name)
+	}
+
+	all := append(slices.Clone(s.middleware), m)
+	for _, phase := range []MiddlewarePhase{PreRouting, PostRouting} {
+		if _, err := orderMiddleware(all, phase); err != nil {
+			return err
+		}
+	}
+	s.middleware = all
+	return nil
+}
+
+// orderMiddleware returns the middleware of the given phase sorted so that all
+// Before and After constraints hold, keeping registration order where unconstrained.
+func orderMiddleware(all []Middleware, phase MiddlewarePhase) ([]mux.MiddlewareFunc, error) {
+	phaseOf := make(map[string]MiddlewarePhase)
+	index := make(map[string]int)
+	var nodes []Middleware
+	for _, m := range all {
+		if m.Name != "" {
+			phaseOf[m.Name] = m.Phase
+		}
+		if m.Phase == phase {
+			if m.Name != "" {
+				index[m.Name] = len(nodes)
+			}
+			nodes = append(nodes, m)
+		}
+	}
+
+	succ := make([][]int, len(nodes))
+	indegree := make([]int, len(nodes))
+	edge := func(from, to int) {
+		succ[from] = append(succ[from], to)
+		indegree[to]++
+	}
+
+	for i, m := range nodes {
+		for _, name := range m.Before {
+			if j, ok := index[name]; ok {
+				edge(i, j)
+			} else if p, ok := phaseOf[name]; ok && p < phase {
+				return nil, fmt.Errorf("%w: %q (%s) cannot run before %q (%s)", ErrMiddlewareOrder, m.Name, phase, name, p)
+			}
+		}
+		for _, name := range m.After {
+			if j, ok := index[name]; ok {
+				edge(j, i)
+			} else if p, ok := phaseOf[name]; ok && p > phase {
+				return nil, fmt.Errorf("%w: %q (%s) cannot run after %q (%s)", ErrMiddlewareOrder, m.Name, phase, name, p)
+			}
+		}
+	}
+
+	// Repeatedly take the earliest registered middleware whose predecessors are all placed.
+	placed := make([]bool, len(nodes))
+	ordered := make([]mux.MiddlewareFunc, 0, len(nodes))
+	for len(ordered) < len(nodes) {
+		next := -1
+		for i := range nodes {
+			if !placed[i] && indegree[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			var cycle []string
+			for i, m := range nodes {
+				if !placed[i] {
+					cycle = append(cycle, fmt.Sprintf("%q", m.Name))
+				}
+			}
+			return nil, fmt.Errorf("%w: cycle between %s", ErrMiddlewareOrder, strings.Join(cycle, ", "))
+		}
+
+		placed[next] = true
+		ordered = append(ordered, nodes[next].Func)
+		for _, j := range succ[next] {
+			indegree[j]--
+		}
+	}
+	return ordered, nil
+}
+
+// handler builds the root handler from the router and the pre-routing middleware.
+func (s *WebServer) handler(preRouting []mux.MiddlewareFunc) http.Handler {
+	var h http.Handler = s.router
+	for i := len(preRouting) - 1; i >= 0; i-- {
+		h = preRouting[i](h)
+	}
+	return h
+}
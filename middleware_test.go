@@ -0,0 +1,102 @@
+package webserver
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func tagMiddleware(tag string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Order", tag)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func serve(s *WebServer, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	s := New(Config{})
+	s.Router().HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	steps := []Middleware{
+		{Name: "post", Phase: PostRouting, Func: tagMiddleware("post")},
+		{Name: "log", Phase: PreRouting, Func: tagMiddleware("log")},
+		{Name: "auth", Phase: PreRouting, Before: []string{"log"}, Func: tagMiddleware("auth")},
+		{Name: "trace", Phase: PreRouting, After: []string{"auth"}, Before: []string{"log"}, Func: tagMiddleware("trace")},
+	}
+	for _, m := range steps {
+		if err := s.Register(m); err != nil {
+			t.Fatalf("Register(%q): %v", m.Name, err)
+		}
+	}
+	s.prepare()
+
+	got := strings.Join(serve(s, "/").Header()["X-Order"], ",")
+	if want := "auth,trace,log,post"; got != want {
+		t.Errorf("order = %q, want %q", got, want)
+	}
+	if got := strings.Join(serve(s, "/missing").Header()["X-Order"], ","); got != "auth,trace,log" {
+		t.Errorf("order for unmatched route = %q, want pre-routing only", got)
+	}
+}
+
+func TestMiddlewareRegisterErrors(t *testing.T) {
+	s := New(Config{})
+	mw := tagMiddleware("x")
+
+	if err := s.Register(Middleware{Name: "a", Phase: PreRouting, Func: mw}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register(Middleware{Name: "post", Phase: PostRouting, Func: mw}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		m    Middleware
+		want error
+	}{
+		{"duplicate", Middleware{Name: "a", Phase: PreRouting, Func: mw}, ErrDuplicateMiddleware},
+		{"cycle", Middleware{Name: "b", Phase: PreRouting, Before: []string{"a"}, After: []string{"a"}, Func: mw}, ErrMiddlewareOrder},
+		{"before earlier phase", Middleware{Name: "c", Phase: PostRouting, Before: []string{"a"}, Func: mw}, ErrMiddlewareOrder},
+		{"after later phase", Middleware{Name: "d", Phase: PreRouting, After: []string{"post"}, Func: mw}, ErrMiddlewareOrder},
+		{"unknown phase", Middleware{Name: "e", Phase: MiddlewarePhase(7), Func: mw}, ErrUnknownPhase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.Register(tt.m); !errors.Is(err, tt.want) {
+				t.Errorf("Register() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	s.prepare()
+	if err := s.Use(PostRouting, mw); !errors.Is(err, ErrServerStarted) {
+		t.Errorf("Use after start = %v, want ErrServerStarted", err)
+	}
+}
+
+func TestRouterMiddleware(t *testing.T) {
+	s := New(Config{})
+	s.Router().Use(tagMiddleware("router"))
+	s.Router().HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	if err := s.Use(PostRouting, tagMiddleware("post")); err != nil {
+		t.Fatal(err)
+	}
+	s.prepare()
+
+	if got := strings.Join(serve(s, "/").Header()["X-Order"], ","); got != "router,post" {
+		t.Errorf("order = %q, want router middleware before post-routing middleware", got)
+	}
+}
@@ -0,0 +1,116 @@
+package webserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+)
+
+// PanicReport describes a panic recovered while serving a request.
+type PanicReport struct {
+	Value   any           // Value passed to panic.
+	Stack   []byte        // Stack trace of the panicking goroutine.
+	Request *http.Request // Request being served; its context carries any request-scoped values such as the user.
+}
+
+// ErrorReporter forwards recovered panics to an external error tracker such as Sentry.
+type ErrorReporter interface {
+	ReportPanic(ctx context.Context, report PanicReport)
+}
+
+// ErrorReporterFunc adapts a function to an ErrorReporter.
+type ErrorReporterFunc func(ctx context.Context, report PanicReport)
+
+// ReportPanic calls f(ctx, report).
+func (f ErrorReporterFunc) ReportPanic(ctx context.Context, report PanicReport) {
+	f(ctx, report)
+}
+
+// SetErrorReporter sets the reporter that receives panics recovered by the server.
+func (s *WebServer) SetErrorReporter(r ErrorReporter) {
+	s.reporter = r
+}
+
+// recoverer recovers panics from the handlers it wraps, logs them with their
+// stack trace and forwards them to the error reporter. If nothing has been
+// written yet it responds with a JSON 500, otherwise it aborts the connection.
+func (s *WebServer) recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &recoverWriter{ResponseWriter: w}
+
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if err, ok := v.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(v) // Deliberate abort, let net/http handle it.
+			}
+
+			stack := debug.Stack()
+			s.log.Error("Panic while serving request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"panic", fmt.Sprint(v),
+				"stack", string(stack),
+			)
+			if s.reporter != nil {
+				s.reporter.ReportPanic(r.Context(), PanicReport{Value: v, Stack: stack, Request: r})
+			}
+
+			if rw.wroteHeader {
+				// The client already has part of a response; cut the connection so
+				// it cannot mistake a truncated body for a complete one.
+				panic(http.ErrAbortHandler)
+			}
+
+			// Drop whatever the handler set (encoding, validators, caching, cookies).
+			clear(w.Header())
+			w.Header().Set("Cache-Control", "no-store")
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal server error"})
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// recoverWriter records whether the response has been started.
+type recoverWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *recoverWriter) WriteHeader(code int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recoverWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *recoverWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *recoverWriter) Flush() {
+	w.wroteHeader = true
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *recoverWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	w.wroteHeader = true
+	return h.Hijack()
+}
@@ -0,0 +1,64 @@
+package webserver
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestRecoverBeforeResponse(t *testing.T) {
+	s := New(Config{})
+	var reported []any
+	s.SetErrorReporter(ErrorReporterFunc(func(ctx context.Context, p PanicReport) {
+		reported = append(reported, p.Value)
+	}))
+	s.Router().HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		w.Header().Set("Set-Cookie", "session=1")
+		panic("boom")
+	})
+	s.prepare()
+
+	rec := serve(s, "/")
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if got := rec.Body.String(); got != "{\"error\":\"internal server error\"}\n" {
+		t.Errorf("body = %q", got)
+	}
+	for _, h := range []string{"Content-Encoding", "ETag", "Set-Cookie"} {
+		if v := rec.Header().Get(h); v != "" {
+			t.Errorf("%s = %q leaked into the 500 response", h, v)
+		}
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+	if len(reported) != 1 || reported[0] != "boom" {
+		t.Errorf("reported = %v, want [boom]", reported)
+	}
+}
+
+func TestRecoverAfterResponseStarted(t *testing.T) {
+	s := New(Config{})
+	reported := 0
+	s.SetErrorReporter(ErrorReporterFunc(func(ctx context.Context, p PanicReport) { reported++ }))
+	s.Router().HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		panic("boom")
+	})
+	s.prepare()
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("panic = %v, want http.ErrAbortHandler so the connection is aborted", v)
+		}
+		if reported != 1 {
+			t.Errorf("reported %d panics, want 1", reported)
+		}
+	}()
+	serve(s, "/")
+	t.Error("handler returned normally after a panic mid-response")
+}
@@ -0,0 +1,76 @@
+package webserver
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReplayProtection(t *testing.T) {
+	h := ReplayProtection(ReplayConfig{MaxSkew: time.Minute})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(timestamp, nonce string) int {
+		r := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+		if timestamp != "" {
+			r.Header.Set("X-Timestamp", timestamp)
+		}
+		if nonce != "" {
+			r.Header.Set("X-Nonce", nonce)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Code
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		nonce     string
+		want      int
+	}{
+		{"fresh", now, "a", http.StatusOK},
+		{"replayed", now, "a", http.StatusUnauthorized},
+		{"new nonce", now, "b", http.StatusOK},
+		{"stale", strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10), "c", http.StatusUnauthorized},
+		{"from the future", strconv.FormatInt(time.Now().Add(2*time.Minute).Unix(), 10), "d", http.StatusUnauthorized},
+		{"missing nonce", now, "", http.StatusBadRequest},
+		{"missing timestamp", "", "e", http.StatusBadRequest},
+		{"malformed timestamp", "yesterday", "f", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if got := send(tt.timestamp, tt.nonce); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReplayCache(t *testing.T) {
+	c := NewReplayCache(time.Minute, 2)
+	now := time.Now()
+
+	if err := c.Add("a", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Add("a", now.Add(30*time.Second)); !errors.Is(err, ErrReplayed) {
+		t.Errorf("Add(a) within TTL = %v, want ErrReplayed", err)
+	}
+	if err := c.Add("b", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Add("c", now); !errors.Is(err, ErrReplayCacheFull) {
+		t.Errorf("Add(c) when full = %v, want ErrReplayCacheFull", err)
+	}
+
+	// Once the TTL has passed, nonces are forgotten and room is made again.
+	later := now.Add(2 * time.Minute)
+	if err := c.Add("a", later); err != nil {
+		t.Errorf("Add(a) after TTL = %v, want nil", err)
+	}
+	if err := c.Add("c", later); err != nil {
+		t.Errorf("Add(c) after expiry = %v, want nil", err)
+	}
+}
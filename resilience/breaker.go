@@ -0,0 +1,160 @@
+// Package resilience provides helpers for making outbound calls robust against
+// slow or failing targets: a circuit breaker, retries with jitter, a timeout
+// wrapper and an http.RoundTripper combining them per target host.
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned when a call is rejected because the circuit breaker is open.
+var ErrOpen = errors.New("resilience: circuit breaker is open")
+
+// State is the state of a circuit breaker.
+type State int
+
+const (
+	// Closed lets all calls through.
+	Closed State = iota
+	// Open rejects all calls until the cooldown has passed.
+	Open
+	// HalfOpen lets a single probe call through to test whether the target has recovered.
+	HalfOpen
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler so states are reported by name.
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Breaker is a circuit breaker. It opens after a number of consecutive failures
+// and, once the cooldown has passed, lets a single probe through to decide
+// whether to close again.
+//
+// Every state change starts a new generation. Outcomes of calls allowed in an
+// earlier generation are ignored, so a slow call started while the breaker was
+// closed cannot close it again or end another call's probe.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+	gen      uint64
+}
+
+// NewBreaker creates a breaker that opens after threshold consecutive failures
+// and stays open for cooldown.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: max(threshold, 1),
+		cooldown:  cooldown,
+	}
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open && time.Since(b.openedAt) >= b.cooldown {
+		return HalfOpen
+	}
+	return b.state
+}
+
+// Allow reports whether a call may proceed, returning ErrOpen if it may not.
+// Every successful Allow must be followed by a call to Record or Release with
+// the returned generation.
+func (b *Breaker) Allow() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open && time.Since(b.openedAt) >= b.cooldown {
+		b.setState(HalfOpen)
+	}
+
+	switch b.state {
+	case Open:
+		return 0, ErrOpen
+	case HalfOpen:
+		if b.probing {
+			return 0, ErrOpen
+		}
+		b.probing = true
+	}
+	return b.gen, nil
+}
+
+// Record records the outcome of a call allowed by Allow in generation gen.
+func (b *Breaker) Record(gen uint64, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if gen != b.gen {
+		return
+	}
+
+	if err == nil {
+		b.failures = 0
+		if b.state != Closed {
+			b.setState(Closed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == HalfOpen || b.failures >= b.threshold {
+		b.setState(Open)
+		b.openedAt = time.Now()
+	}
+}
+
+// Release ends a call allowed by Allow in generation gen without recording an
+// outcome, for calls that were abandoned by the caller and say nothing about
+// the target's health.
+func (b *Breaker) Release(gen uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if gen == b.gen {
+		b.probing = false
+	}
+}
+
+// setState moves the breaker to a new state and generation; b.mu must be held.
+func (b *Breaker) setState(s State) {
+	b.state = s
+	b.probing = false
+	b.gen++
+}
+
+// Do calls fn if the breaker allows it and records the result.
+func (b *Breaker) Do(fn func() error) error {
+	gen, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	b.Record(gen, err)
+	return err
+}
@@ -0,0 +1,102 @@
+package resilience
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var errFailed = errors.New("failed")
+
+func TestBreakerTransitions(t *testing.T) {
+	b := NewBreaker(2, 50*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		if err := b.Do(func() error { return errFailed }); !errors.Is(err, errFailed) {
+			t.Fatalf("call %d = %v, want errFailed", i, err)
+		}
+	}
+	if got := b.State(); got != Open {
+		t.Fatalf("state after %d failures = %s, want open", 2, got)
+	}
+	if err := b.Do(func() error { return nil }); !errors.Is(err, ErrOpen) {
+		t.Fatalf("call while open = %v, want ErrOpen", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if got := b.State(); got != HalfOpen {
+		t.Fatalf("state after cooldown = %s, want half-open", got)
+	}
+
+	// Only a single probe is let through while half-open.
+	gen, err := b.Allow()
+	if err != nil {
+		t.Fatalf("probe rejected: %v", err)
+	}
+	if _, err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("second probe = %v, want ErrOpen", err)
+	}
+	b.Record(gen, nil)
+
+	if got := b.State(); got != Closed {
+		t.Fatalf("state after successful probe = %s, want closed", got)
+	}
+}
+
+func TestBreakerFailedProbeReopens(t *testing.T) {
+	b := NewBreaker(1, 20*time.Millisecond)
+	b.Do(func() error { return errFailed })
+	time.Sleep(30 * time.Millisecond)
+
+	b.Do(func() error { return errFailed })
+	if got := b.State(); got != Open {
+		t.Fatalf("state after failed probe = %s, want open", got)
+	}
+}
+
+func TestBreakerRelease(t *testing.T) {
+	b := NewBreaker(1, 20*time.Millisecond)
+	b.Do(func() error { return errFailed })
+	time.Sleep(30 * time.Millisecond)
+
+	gen, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Release(gen)
+
+	if got := b.State(); got != HalfOpen {
+		t.Fatalf("state after released probe = %s, want half-open", got)
+	}
+	if _, err := b.Allow(); err != nil {
+		t.Fatalf("new probe after release = %v, want allowed", err)
+	}
+}
+
+func TestBreakerIgnoresStaleOutcomes(t *testing.T) {
+	b := NewBreaker(1, 20*time.Millisecond)
+
+	// A slow call allowed while closed finishes only after the breaker opened.
+	slow, _ := b.Allow()
+	b.Do(func() error { return errFailed })
+	b.Record(slow, nil)
+	if got := b.State(); got != Open {
+		t.Fatalf("state after stale success = %s, want open", got)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	probe, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Release(slow)
+	b.Record(slow, errFailed)
+	if _, err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("second probe after stale outcomes = %v, want ErrOpen", err)
+	}
+
+	b.Record(probe, nil)
+	if got := b.State(); got != Closed {
+		t.Fatalf("state after successful probe = %s, want closed", got)
+	}
+}
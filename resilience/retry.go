@@ -0,0 +1,87 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff configures Retry.
+type Backoff struct {
+	Attempts int           // Total number of attempts, including the first.
+	Base     time.Duration // Upper bound of the delay before the first retry.
+	Max      time.Duration // Upper bound of any delay.
+}
+
+// Delay returns the delay before the given retry (starting at 0) using full
+// jitter: a random duration between 0 and min(Max, Base*2^retry).
+func (b Backoff) Delay(retry int) time.Duration {
+	ceiling := b.Base
+	for i := 0; i < retry && ceiling < math.MaxInt64/2 && (b.Max <= 0 || ceiling < b.Max); i++ {
+		ceiling *= 2
+	}
+	if b.Max > 0 && ceiling > b.Max {
+		ceiling = b.Max
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that Retry returns it immediately instead of retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Retry calls fn until it succeeds, returns a Permanent error, the attempts are
+// exhausted or ctx is done. ErrOpen is treated as permanent. The last error is returned.
+func Retry(ctx context.Context, b Backoff, fn func(context.Context) error) error {
+	var err error
+	for attempt := 0; attempt < max(b.Attempts, 1); attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(b.Delay(attempt - 1))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return errors.Join(err, ctx.Err())
+			case <-timer.C:
+			}
+		}
+
+		err = fn(ctx)
+		if err == nil {
+			return nil
+		}
+
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return perm.err
+		}
+		if errors.Is(err, ErrOpen) {
+			return err
+		}
+	}
+	return err
+}
+
+// Timeout calls fn with a context that is cancelled after d.
+// fn is expected to honour the context.
+func Timeout(ctx context.Context, d time.Duration, fn func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	return fn(ctx)
+}
@@ -0,0 +1,103 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Base: 10 * time.Millisecond, Max: 50 * time.Millisecond}
+
+	for retry, ceiling := range []time.Duration{10, 20, 40, 50, 50, 50} {
+		ceiling *= time.Millisecond
+		for i := 0; i < 100; i++ {
+			if d := b.Delay(retry); d < 0 || d >= ceiling {
+				t.Fatalf("Delay(%d) = %s, want within [0, %s)", retry, d, ceiling)
+			}
+		}
+	}
+	if d := b.Delay(1000); d < 0 || d >= b.Max {
+		t.Errorf("Delay(1000) = %s, want clamped below %s", d, b.Max)
+	}
+	if d := (Backoff{}).Delay(3); d != 0 {
+		t.Errorf("zero Backoff Delay = %s, want 0", d)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	b := Backoff{Attempts: 3}
+
+	calls := 0
+	err := Retry(context.Background(), b, func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errFailed
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Retry() = %v after %d calls, want success after 3", err, calls)
+	}
+
+	calls = 0
+	err = Retry(context.Background(), b, func(context.Context) error {
+		calls++
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) || calls != 3 {
+		t.Errorf("Retry() = %v after %d calls, want errFailed after 3", err, calls)
+	}
+}
+
+func TestRetryPermanent(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), Backoff{Attempts: 3}, func(context.Context) error {
+		calls++
+		return Permanent(errFailed)
+	})
+	if err != errFailed || calls != 1 {
+		t.Errorf("Retry() = %#v after %d calls, want unwrapped errFailed after 1", err, calls)
+	}
+	if Permanent(nil) != nil {
+		t.Error("Permanent(nil) != nil")
+	}
+}
+
+func TestRetryOpen(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), Backoff{Attempts: 3}, func(context.Context) error {
+		calls++
+		return ErrOpen
+	})
+	if !errors.Is(err, ErrOpen) || calls != 1 {
+		t.Errorf("Retry() = %v after %d calls, want ErrOpen after 1", err, calls)
+	}
+}
+
+func TestRetryCancelledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := Backoff{Attempts: 3, Base: time.Hour, Max: time.Hour}
+
+	// The context is cancelled after the first attempt, while Retry waits to try again.
+	calls := 0
+	err := Retry(ctx, b, func(context.Context) error {
+		calls++
+		cancel()
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) || !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("Retry() = %v after %d calls, want errFailed joined with context.Canceled after 1", err, calls)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	err := Timeout(context.Background(), 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Timeout() = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,216 @@
+package resilience
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Transport is an http.RoundTripper that guards each target host with its own
+// circuit breaker, retries idempotent requests on transport errors and
+// 502/503/504 responses, and applies a per-attempt timeout.
+// The zero value is not usable; create one with NewTransport.
+type Transport struct {
+	base      http.RoundTripper
+	threshold int
+	cooldown  time.Duration
+	backoff   Backoff
+	timeout   time.Duration
+
+	mu      sync.Mutex
+	targets map[string]*target
+}
+
+// TransportConfig configures a Transport.
+type TransportConfig struct {
+	Base      http.RoundTripper // Underlying transport, http.DefaultTransport if nil.
+	Threshold int               // Consecutive failures before a target's breaker opens.
+	Cooldown  time.Duration     // How long a breaker stays open.
+	Backoff   Backoff           // Retry policy for idempotent requests.
+	Timeout   time.Duration     // Per-attempt timeout, none if 0.
+}
+
+// Stats are the counters collected for a single target host.
+type Stats struct {
+	State    State `json:"state"`
+	Requests int64 `json:"requests"` // Attempts sent to the target.
+	Failures int64 `json:"failures"` // Attempts that failed or returned a 5xx status.
+	Rejected int64 `json:"rejected"` // Attempts rejected by the open breaker.
+	Retries  int64 `json:"retries"`  // Attempts that were retries of an earlier attempt.
+}
+
+type target struct {
+	breaker  *Breaker
+	requests atomic.Int64
+	failures atomic.Int64
+	rejected atomic.Int64
+	retries  atomic.Int64
+}
+
+// NewTransport creates a Transport.
+func NewTransport(cfg TransportConfig) *Transport {
+	base := cfg.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		base:      base,
+		threshold: cfg.Threshold,
+		cooldown:  cfg.Cooldown,
+		backoff:   cfg.Backoff,
+		timeout:   cfg.Timeout,
+		targets:   make(map[string]*target),
+	}
+}
+
+// Stats returns the counters of every target seen so far, keyed by host.
+func (t *Transport) Stats() map[string]Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := make(map[string]Stats, len(t.targets))
+	for host, tgt := range t.targets {
+		stats[host] = Stats{
+			State:    tgt.breaker.State(),
+			Requests: tgt.requests.Load(),
+			Failures: tgt.failures.Load(),
+			Rejected: tgt.rejected.Load(),
+			Retries:  tgt.retries.Load(),
+		}
+	}
+	return stats
+}
+
+func (t *Transport) target(host string) *target {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tgt, ok := t.targets[host]
+	if !ok {
+		tgt = &target{breaker: NewBreaker(t.threshold, t.cooldown)}
+		t.targets[host] = tgt
+	}
+	return tgt
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tgt := t.target(req.URL.Host)
+
+	backoff := t.backoff
+	if !replayable(req) {
+		backoff.Attempts = 1
+	}
+
+	var resp *http.Response
+	attempt := 0
+	bodySent := false
+	err := Retry(req.Context(), backoff, func(ctx context.Context) error {
+		if attempt > 0 {
+			tgt.retries.Add(1)
+		}
+		attempt++
+
+		gen, err := tgt.breaker.Allow()
+		if err != nil {
+			tgt.rejected.Add(1)
+			return err
+		}
+		tgt.requests.Add(1)
+
+		r, err := t.send(ctx, req, bodySent)
+		bodySent = true
+		if err != nil && req.Context().Err() != nil {
+			// The caller gave up; that says nothing about the target.
+			tgt.breaker.Release(gen)
+			return Permanent(err)
+		}
+		if err == nil && r.StatusCode >= http.StatusInternalServerError {
+			err = fmt.Errorf("resilience: %s responded %s", req.URL.Host, r.Status)
+		}
+		tgt.breaker.Record(gen, err)
+		if err == nil {
+			resp = r
+			return nil
+		}
+		tgt.failures.Add(1)
+
+		if r == nil {
+			return err
+		}
+		switch r.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			if attempt < backoff.Attempts {
+				io.Copy(io.Discard, r.Body)
+				r.Body.Close()
+				return err
+			}
+		}
+		// Not retryable, or out of attempts: hand the response back to the caller as is.
+		resp = r
+		return Permanent(err)
+	})
+
+	// A RoundTripper must close the request body, even when it never sends it.
+	if !bodySent && req.Body != nil {
+		req.Body.Close()
+	}
+
+	if resp != nil {
+		return resp, nil
+	}
+	return nil, err
+}
+
+// send performs a single attempt. The original request body is used for the
+// first attempt that is sent; later attempts get a fresh body from GetBody.
+func (t *Transport) send(ctx context.Context, req *http.Request, rewind bool) (*http.Response, error) {
+	cancel := context.CancelFunc(func() {})
+	if t.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+	}
+
+	r := req.WithContext(ctx)
+	if rewind && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		r.Body = body
+	}
+
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The timeout covers reading the body too, so only release it once the body is closed.
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// replayable reports whether req can safely be sent more than once.
+func replayable(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
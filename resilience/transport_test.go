@@ -0,0 +1,179 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestTransport() *Transport {
+	return NewTransport(TransportConfig{
+		Threshold: 3,
+		Cooldown:  time.Minute,
+		Backoff:   Backoff{Attempts: 3, Base: time.Millisecond, Max: 5 * time.Millisecond},
+	})
+}
+
+func TestTransportRetriesPerMethod(t *testing.T) {
+	tests := []struct {
+		method string
+		calls  int32
+	}{
+		{http.MethodGet, 3},
+		{http.MethodPut, 3},
+		{http.MethodDelete, 3},
+		{http.MethodPost, 1},
+		{http.MethodPatch, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer srv.Close()
+
+			req, _ := http.NewRequest(tt.method, srv.URL, strings.NewReader("body"))
+			resp, err := (&http.Client{Transport: newTestTransport()}).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("status = %d, want 503", resp.StatusCode)
+			}
+			if got := calls.Load(); got != tt.calls {
+				t.Errorf("upstream calls = %d, want %d", got, tt.calls)
+			}
+		})
+	}
+}
+
+func TestTransportRetryResendsBody(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if string(b) != "payload" {
+			t.Errorf("attempt %d body = %q", calls.Load()+1, b)
+		}
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("payload"))
+	resp, err := (&http.Client{Transport: newTestTransport()}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 2 {
+		t.Errorf("status = %d after %d calls, want 200 after 2", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestTransportBreakerOpensAndRecovers(t *testing.T) {
+	var healthy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	tr := NewTransport(TransportConfig{Threshold: 2, Cooldown: 50 * time.Millisecond, Backoff: Backoff{Attempts: 1}})
+	client := &http.Client{Transport: tr}
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if got := tr.Stats()[host].State; got != Open {
+		t.Fatalf("state = %s, want open", got)
+	}
+	if _, err := client.Get(srv.URL); !errors.Is(err, ErrOpen) {
+		t.Fatalf("request while open = %v, want ErrOpen", err)
+	}
+
+	healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	if got := tr.Stats()[host].State; got != HalfOpen {
+		t.Fatalf("state after cooldown = %s, want half-open", got)
+	}
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	stats := tr.Stats()[host]
+	if stats.State != Closed {
+		t.Errorf("state after successful probe = %s, want closed", stats.State)
+	}
+	if stats.Requests != 3 || stats.Failures != 2 || stats.Rejected != 1 {
+		t.Errorf("stats = %+v, want 3 requests, 2 failures, 1 rejected", stats)
+	}
+}
+
+func TestTransportCallerCancellationIsNotAFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	tr := NewTransport(TransportConfig{Threshold: 1, Cooldown: time.Minute, Backoff: Backoff{Attempts: 3}})
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		if _, err := tr.RoundTrip(req); err == nil {
+			t.Fatal("expected the caller's deadline to fail the request")
+		}
+		cancel()
+	}
+
+	stats := tr.Stats()[strings.TrimPrefix(srv.URL, "http://")]
+	if stats.State != Closed || stats.Failures != 0 {
+		t.Errorf("stats = %+v, want closed breaker without failures", stats)
+	}
+}
+
+type trackingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestTransportClosesBodyWhenRejected(t *testing.T) {
+	tr := NewTransport(TransportConfig{Threshold: 1, Cooldown: time.Minute})
+	tr.target("example.invalid").breaker.Do(func() error { return errFailed })
+
+	body := &trackingBody{Reader: strings.NewReader("body")}
+	req, _ := http.NewRequest(http.MethodPost, "http://example.invalid/", body)
+	if _, err := tr.RoundTrip(req); !errors.Is(err, ErrOpen) {
+		t.Fatalf("RoundTrip() = %v, want ErrOpen", err)
+	}
+	if !body.closed {
+		t.Error("request body was not closed")
+	}
+}
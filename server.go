@@ -4,8 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -18,6 +22,12 @@ type WebServer struct {
 	stopServer chan error
 	wg         sync.WaitGroup
 	log        *slog.Logger
+	reporter   ErrorReporter
+
+	mu         sync.Mutex
+	started    bool
+	middleware []Middleware
+	ready      atomic.Bool
 }
 
 // New creates a new server.
@@ -25,7 +35,7 @@ func New(cfg Config) *WebServer {
 
 	r := mux.NewRouter()
 
-	return &WebServer{
+	s := &WebServer{
 		cfg:    cfg,
 		router: r,
 		server: &http.Server{
@@ -35,6 +45,17 @@ func New(cfg Config) *WebServer {
 		stopServer: make(chan error),
 		log:        slog.Default(),
 	}
+
+	s.Register(Middleware{Name: RecoverMiddleware, Phase: PreRouting, Func: s.recoverer})
+
+	if cfg.Limits.MaxInFlight > 0 {
+		// Probes must keep answering under load, or a busy server gets restarted.
+		limits := cfg.Limits
+		limits.ExemptPaths = append(slices.Clone(limits.ExemptPaths), healthPaths...)
+		s.Register(Middleware{Name: LimitMiddleware, Phase: PreRouting, After: []string{RecoverMiddleware}, Func: LimitInFlight(limits)})
+	}
+
+	return s
 }
 
 // SetLogger sets the logger for the server.
@@ -47,8 +68,13 @@ func (s *WebServer) GetLogger() *slog.Logger {
 	return s.log
 }
 
-// Stop gracefully stops the server.
+// Stop gracefully stops the server. The server first reports itself as not ready
+// and keeps serving for Config.DrainDelayMS, so load balancers polling /ready can
+// take it out of rotation before the listener is closed.
 func (s *WebServer) Stop() error {
+	s.ready.Store(false)
+	time.Sleep(time.Duration(s.cfg.DrainDelayMS) * time.Millisecond)
+
 	go func() {
 		if s.stopServer != nil {
 			close(s.stopServer)
@@ -64,11 +90,13 @@ func (s *WebServer) Stop() error {
 
 // Run the web server (blocking).
 func (s *WebServer) Run() error {
+	s.prepare()
 	return s.listenAndServe()
 }
 
 // Start the web server asynchronously (does not block).
 func (s *WebServer) Start() {
+	s.prepare()
 	s.wg.Add(1)
 
 	go func() {
@@ -80,16 +108,41 @@ func (s *WebServer) Start() {
 	}()
 }
 
+// prepare freezes the middleware chain and installs it as the server handler.
+func (s *WebServer) prepare() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Both orders were validated when the middleware was registered.
+	preRouting, _ := orderMiddleware(s.middleware, PreRouting)
+	postRouting, _ := orderMiddleware(s.middleware, PostRouting)
+
+	s.started = true
+	s.router.Use(postRouting...)
+	s.server.Handler = s.handler(preRouting)
+}
+
 func (s *WebServer) listenAndServe() error {
+	ln, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return err
+	}
+
+	// Only report ready while the listener is bound and being served.
+	s.ready.Store(true)
+	defer s.ready.Store(false)
+
 	if s.cfg.TLS.Enabled {
 		s.log.Info(fmt.Sprintf("Listening on https://%s:%d", s.cfg.Addr, s.cfg.Port))
-		return s.server.ListenAndServeTLS(s.cfg.TLS.CertFile, s.cfg.TLS.KeyFile)
+		return s.server.ServeTLS(ln, s.cfg.TLS.CertFile, s.cfg.TLS.KeyFile)
 	}
 	s.log.Info(fmt.Sprintf("Listening on http://%s:%d", s.cfg.Addr, s.cfg.Port))
-	return s.server.ListenAndServe()
+	return s.server.Serve(ln)
 }
 
-// Router returns the router for the server.
+// Router returns the router for the server. Routes and middleware added with
+// Router().Use or on subrouters must be set up before Start or Run; prefer Use or
+// Register for middleware so that it is ordered with the server's own.
 func (s *WebServer) Router() *mux.Router {
 	return s.router
 }